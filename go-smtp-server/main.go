@@ -2,14 +2,24 @@ package main
 
 import (
 	"log"
+
+	"smtpmini/queue"
+	"smtpmini/smtpclient"
+	"smtpmini/smtpsrv"
 )
 
 // main is the entry point of the application
 // It starts the SMTP server on port 2525 and blocks indefinitely
 func main() {
-	launchScheduler() // <‑‑ start the retry delivery goroutine
+	q := queue.New("spool")
+	scheduler := queue.NewScheduler(q, smtpclient.Deliver)
+	scheduler.Start() // <‑‑ start the retry delivery goroutine
 
-	if _, _, err := Start(":2525"); err != nil {
+	// No Authenticator is wired up yet, so AUTH stays unadvertised and every
+	// sender is accepted as before. The server caps messages at 25 MiB.
+	backend := NewSpoolBackend(q)
+	srv := smtpsrv.New(smtpsrv.WithBackend(backend), smtpsrv.WithMaxSize(25<<20))
+	if _, _, err := srv.Start(":2525"); err != nil {
 		log.Fatal(err)
 	}
 	select {} // Block forever (until process is terminated)
@@ -0,0 +1,100 @@
+// dsn.go
+package queue
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ─── permanent vs transient delivery errors ────────────────────────────────────
+
+// PermanentError marks a delivery failure as permanent (an SMTP 5xx reply),
+// meaning retrying won't help and Scheduler should bounce immediately instead
+// of backing off. Constructed by smtpclient when it sees a 5xx reply.
+type PermanentError struct{ Err error }
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// ─── DSN generation (RFC 3464) ─────────────────────────────────────────────────
+
+// DSNIncludeFullBody controls whether the returned message/rfc822 part of a
+// bounce carries the whole original message or just its headers.
+const DSNIncludeFullBody = false
+
+// statusCodeFor derives an RFC 3463 enhanced status code for a delivery
+// failure. A PermanentError carrying an SMTP reply code is translated
+// directly; anything else (queue-age expiry, network errors) gets a
+// generic "other" code.
+func statusCodeFor(err error) string {
+	perr, ok := err.(*PermanentError)
+	if !ok {
+		return "5.4.0" // Other or undefined network or routing problem
+	}
+	if smtpErr, ok := perr.Err.(*textproto.Error); ok {
+		return fmt.Sprintf("5.%d.%d", (smtpErr.Code/10)%10, smtpErr.Code%10)
+	}
+	return "5.0.0"
+}
+
+// messageHeaders returns the header block of an RFC-822 message (everything
+// up to the first blank line), for bounces that don't want to echo the body.
+func messageHeaders(data string) string {
+	if idx := strings.Index(data, "\r\n\r\n"); idx != -1 {
+		return data[:idx+2]
+	}
+	return data
+}
+
+// buildDSN constructs a multipart/report delivery-status-notification bound
+// for the original sender of m, describing why delivery to rcpt failed. The
+// returned Message has a null envelope sender so the DSN itself can never
+// generate another bounce. hostname names the reporting MTA.
+func buildDSN(hostname string, m *Message, rcpt string, deliveryErr error) *Message {
+	status := statusCodeFor(deliveryErr)
+	boundary := fmt.Sprintf("dsn-%d", time.Now().UnixNano())
+
+	var originalPart string
+	if DSNIncludeFullBody {
+		originalPart = m.Data
+	} else {
+		originalPart = messageHeaders(m.Data)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Content-Type: multipart/report; report-type=delivery-status;\r\n")
+	fmt.Fprintf(&body, "\tboundary=\"%s\"\r\n", boundary)
+	fmt.Fprintf(&body, "Subject: Undelivered Mail Returned to Sender\r\n")
+	fmt.Fprintf(&body, "From: Mail Delivery Subsystem <mailer-daemon@%s>\r\n", hostname)
+	fmt.Fprintf(&body, "To: %s\r\n\r\n", m.From)
+
+	fmt.Fprintf(&body, "--%s\r\n", boundary)
+	fmt.Fprintf(&body, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "This is the mail system at %s.\r\n\r\n", hostname)
+	fmt.Fprintf(&body, "Delivery to the following recipient failed permanently:\r\n\r\n")
+	fmt.Fprintf(&body, "    %s\r\n\r\n", rcpt)
+	fmt.Fprintf(&body, "Reason: %s\r\n\r\n", deliveryErr)
+
+	fmt.Fprintf(&body, "--%s\r\n", boundary)
+	fmt.Fprintf(&body, "Content-Type: message/delivery-status\r\n\r\n")
+	fmt.Fprintf(&body, "Reporting-MTA: dns;%s\r\n", hostname)
+	fmt.Fprintf(&body, "\r\n")
+	fmt.Fprintf(&body, "Final-Recipient: rfc822;%s\r\n", rcpt)
+	fmt.Fprintf(&body, "Action: failed\r\n")
+	fmt.Fprintf(&body, "Status: %s\r\n", status)
+	fmt.Fprintf(&body, "Diagnostic-Code: smtp; %s\r\n", deliveryErr)
+
+	fmt.Fprintf(&body, "--%s\r\n", boundary)
+	fmt.Fprintf(&body, "Content-Type: message/rfc822\r\n\r\n")
+	body.WriteString(originalPart)
+	fmt.Fprintf(&body, "--%s--\r\n", boundary)
+
+	return &Message{
+		From:     m.From, // who the bounce is addressed to, for bookkeeping
+		Rcpts:    []string{m.From},
+		Data:     body.String(),
+		Envelope: Envelope{From: ""}, // null sender: this message can't itself bounce
+	}
+}
@@ -0,0 +1,38 @@
+// message.go
+package queue
+
+import "time"
+
+// ─── on‑disk message schema ───────────────────────────────────────────────────
+
+type Message struct {
+	ID        string         `json:"id"`
+	From      string         `json:"from"` // sender to notify on bounce; see Envelope for the wire-level MAIL FROM
+	Rcpts     []string       `json:"rcpts"`
+	Data      string         `json:"data"` // full RFC‑822 string ending in \r\n
+	Attempts  int            `json:"attempts"`
+	LastError string         `json:"last_error,omitempty"`
+	NextTry   time.Time      `json:"next_try"`
+	Created   time.Time      `json:"created"`
+	Policy    DeliveryPolicy `json:"policy,omitempty"`
+	Envelope  Envelope       `json:"envelope"`
+}
+
+// Envelope carries the SMTP-level sender, which is distinct from From: a
+// DSN bounce is addressed (From) back to the original sender but must itself
+// go out with a null envelope sender so it can never bounce again.
+type Envelope struct {
+	From string `json:"from"` // MAIL FROM address actually put on the wire; "" means "<>"
+}
+
+// DeliveryPolicy lets a message override the default "best-effort TLS"
+// outbound behaviour with stricter, RFC 7672 / MTA-STS-backed requirements.
+type DeliveryPolicy struct {
+	RequireTLS  bool   `json:"require_tls,omitempty"`  // refuse to send in cleartext at all
+	MTASTSMode  string `json:"mta_sts_mode,omitempty"` // "", "testing", or "enforce" override
+	// DANEEnabled makes DANE verification mandatory: delivery defers instead
+	// of falling back to PKIX/cleartext when TLSA records can't be
+	// DNSSEC-validated. DANE itself is checked automatically whenever
+	// validated TLSA records resolve, regardless of this flag.
+	DANEEnabled bool `json:"dane_enabled,omitempty"`
+}
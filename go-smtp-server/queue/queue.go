@@ -0,0 +1,77 @@
+// queue.go
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Queue is a disk-backed spool of Messages awaiting delivery. Each message is
+// one JSON file in Dir; Scheduler polls LoadQueued to find work.
+type Queue struct {
+	Dir string
+
+	// Hostname names this queue in the "From:"/"Reporting-MTA:" lines of any
+	// DSN it generates. Defaults to os.Hostname().
+	Hostname string
+}
+
+// New returns a Queue backed by dir, creating it if necessary. An empty dir
+// defaults to "spool".
+func New(dir string) *Queue {
+	if dir == "" {
+		dir = "spool"
+	}
+	_ = os.MkdirAll(dir, 0o755)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "smtpmini"
+	}
+	return &Queue{Dir: dir, Hostname: hostname}
+}
+
+func (q *Queue) Enqueue(m *Message) error {
+	m.ID = fmt.Sprintf("%d-%s.json", time.Now().UnixNano(), strings.ReplaceAll(m.From, "@", "_"))
+	m.NextTry = time.Now()
+	m.Created = time.Now()
+	f, err := os.Create(filepath.Join(q.Dir, m.ID))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(m)
+}
+
+func (q *Queue) LoadQueued() ([]*Message, error) {
+	var ms []*Message
+	err := filepath.WalkDir(q.Dir, func(p string, d fs.DirEntry, _ error) error {
+		if d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return nil
+		}
+		var m Message
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return err
+		}
+		ms = append(ms, &m)
+		return nil
+	})
+	return ms, err
+}
+
+func (q *Queue) Remove(m *Message) { _ = os.Remove(filepath.Join(q.Dir, m.ID)) }
+
+func (q *Queue) Persist(m *Message) {
+	f, _ := os.Create(filepath.Join(q.Dir, m.ID))
+	defer f.Close()
+	_ = json.NewEncoder(f).Encode(m)
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextRetryRespectsCap(t *testing.T) {
+	d := nextRetry(20) // way past where base*2^attempts would overflow the cap
+	if d < retryCap || d > retryCap+retryBase {
+		t.Fatalf("nextRetry(20) = %v, want within [%v, %v]", d, retryCap, retryCap+retryBase)
+	}
+}
+
+func TestBuildDSNHasNullEnvelope(t *testing.T) {
+	m := &Message{
+		From:     "sender@example.com",
+		Rcpts:    []string{"rcpt@example.org"},
+		Data:     "Subject: hi\r\nFrom: sender@example.com\r\n\r\nbody\r\n",
+		Envelope: Envelope{From: "sender@example.com"},
+	}
+	dsn := buildDSN("mail.example.com", m, "rcpt@example.org", fmt.Errorf("550 5.1.1 unknown user"))
+
+	if dsn.Envelope.From != "" {
+		t.Fatalf("DSN envelope sender = %q, want empty (null sender)", dsn.Envelope.From)
+	}
+	if len(dsn.Rcpts) != 1 || dsn.Rcpts[0] != m.From {
+		t.Fatalf("DSN should be addressed back to the original sender, got %v", dsn.Rcpts)
+	}
+	if !strings.Contains(dsn.Data, "multipart/report") {
+		t.Fatalf("DSN body missing multipart/report content type")
+	}
+	if !strings.Contains(dsn.Data, "Final-Recipient: rfc822;rcpt@example.org") {
+		t.Fatalf("DSN body missing Final-Recipient field")
+	}
+}
+
+func TestEnqueueSetsCreatedTime(t *testing.T) {
+	q := New(t.TempDir())
+	before := time.Now()
+	m := &Message{From: "a@b", Rcpts: []string{"c@d"}, Data: "x\r\n", Envelope: Envelope{From: "a@b"}}
+	if err := q.Enqueue(m); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if m.Created.Before(before) {
+		t.Fatalf("Created not set at enqueue time")
+	}
+}
@@ -0,0 +1,104 @@
+// scheduler.go
+package queue
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+// ─── retry scheduler ─────────────────────────────────────────────────────────
+
+const (
+	retryBase = 5 * time.Minute
+	retryCap  = 6 * time.Hour
+	// MaxQueueAge is how long a message may sit in a Queue before Scheduler
+	// gives up on it and bounces it back to the sender.
+	MaxQueueAge = 5 * 24 * time.Hour
+)
+
+// Scheduler polls a Queue for messages whose NextTry has arrived and hands
+// each to Deliver, backing off transient failures exponentially and bouncing
+// permanent ones (or anything that's simply aged out).
+type Scheduler struct {
+	Queue *Queue
+
+	// Deliver attempts one delivery of m, returning a *PermanentError when
+	// the failure is a 5xx the remote MTA is never going to reconsider.
+	Deliver func(m *Message) error
+
+	// Interval is how often the queue is swept for due messages.
+	Interval time.Duration
+}
+
+// NewScheduler returns a Scheduler that delivers via deliver, polling q once
+// a minute.
+func NewScheduler(q *Queue, deliver func(m *Message) error) *Scheduler {
+	return &Scheduler{Queue: q, Deliver: deliver, Interval: 1 * time.Minute}
+}
+
+// Start launches the retry loop in its own goroutine.
+func (s *Scheduler) Start() {
+	go func() {
+		for {
+			msgs, _ := s.Queue.LoadQueued()
+			now := time.Now()
+			for _, m := range msgs {
+				if m.NextTry.After(now) {
+					continue
+				}
+				err := s.Deliver(m)
+				if err == nil {
+					s.Queue.Remove(m)
+					log.Printf("[queue] delivered %s", m.ID)
+					continue
+				}
+
+				_, permanent := err.(*PermanentError)
+				expired := now.Sub(m.Created) > MaxQueueAge
+				if permanent || expired {
+					s.bounce(m, err)
+					s.Queue.Remove(m)
+					continue
+				}
+
+				m.Attempts++
+				m.LastError = err.Error()
+				m.NextTry = now.Add(nextRetry(m.Attempts))
+				s.Queue.Persist(m)
+				log.Printf("[queue] defer %s (%d tries): %v", m.ID, m.Attempts, err)
+			}
+			time.Sleep(s.Interval)
+		}
+	}()
+}
+
+// nextRetry computes the exponentially-backed-off retry delay for a message
+// that has failed attempts times: min(cap, base * 2^attempts) + a random
+// jitter in [0, base), to avoid every deferred message retrying in lockstep.
+func nextRetry(attempts int) time.Duration {
+	backoff := retryBase * time.Duration(1<<uint(attempts))
+	if backoff > retryCap || backoff <= 0 { // overflow guard for large attempts
+		backoff = retryCap
+	}
+	jitter := time.Duration(rand.Int63n(int64(retryBase)))
+	return backoff + jitter
+}
+
+// bounce generates and enqueues a DSN for each recipient of m, notifying the
+// original sender that delivery has permanently failed (or aged out).
+func (s *Scheduler) bounce(m *Message, deliveryErr error) {
+	if m.Envelope.From == "" {
+		// Already a DSN (null sender): drop it rather than bounce a bounce.
+		log.Printf("[queue] dropping undeliverable DSN %s: %v", m.ID, deliveryErr)
+		return
+	}
+	for _, rcpt := range m.Rcpts {
+		dsn := buildDSN(s.Queue.Hostname, m, rcpt, deliveryErr)
+		if err := s.Queue.Enqueue(dsn); err != nil {
+			log.Printf("[queue] failed to enqueue DSN for %s: %v", m.ID, err)
+			continue
+		}
+		log.Printf("[queue] bounced %s to %s: %v", m.ID, m.From, deliveryErr)
+	}
+}
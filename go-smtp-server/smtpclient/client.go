@@ -0,0 +1,174 @@
+// client.go
+package smtpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"smtpmini/queue"
+)
+
+// clientHostname is the identity smtpmini presents in its own EHLO/LHLO
+// lines when delivering outbound mail.
+const clientHostname = "smtpmini.local"
+
+// Client is a connection to a remote MTA (or local delivery agent, for LMTP)
+// in the middle of an SMTP/LMTP transaction.
+type Client struct {
+	conn net.Conn
+	tp   *textproto.Conn
+}
+
+// Dial connects to addr over network ("tcp" or "unix") and reads the
+// initial 220 greeting.
+func Dial(network, addr string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, tp: textproto.NewConn(conn)}
+	if _, _, err := c.tp.ReadResponse(220); err != nil {
+		conn.Close()
+		return nil, classifyError(err)
+	}
+	return c, nil
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error { return c.conn.Close() }
+
+// Ehlo sends EHLO and drains the capability lines, reporting whether
+// STARTTLS was advertised.
+func (c *Client) Ehlo(hostname string) (supportsTLS bool, err error) {
+	if err = c.tp.PrintfLine("EHLO %s", hostname); err != nil {
+		return false, err
+	}
+	return c.drainGreeting()
+}
+
+// Lhlo sends LHLO, LMTP's stand-in for EHLO (RFC 2033 §4).
+func (c *Client) Lhlo(hostname string) error {
+	if err := c.tp.PrintfLine("LHLO %s", hostname); err != nil {
+		return err
+	}
+	_, err := c.drainGreeting()
+	return err
+}
+
+// drainGreeting reads the capability lines following an already-sent
+// EHLO/LHLO, reporting whether STARTTLS was advertised.
+func (c *Client) drainGreeting() (supportsTLS bool, err error) {
+	for {
+		line, e := c.tp.ReadLine()
+		if e != nil {
+			return false, classifyError(e)
+		}
+		if strings.HasPrefix(line, "250-") {
+			if strings.Contains(line, "STARTTLS") {
+				supportsTLS = true
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "250 ") { // last line
+			if strings.Contains(line, "STARTTLS") {
+				supportsTLS = true
+			}
+			return supportsTLS, nil
+		}
+		return false, classifyError(&textproto.Error{Code: 500, Msg: "unexpected EHLO line: " + line})
+	}
+}
+
+// StartTLS issues STARTTLS and upgrades the connection, per RFC 3207.
+func (c *Client) StartTLS(cfg *tls.Config) error {
+	if err := c.tp.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := c.tp.ReadResponse(220); err != nil {
+		return classifyError(err)
+	}
+	tlsConn := tls.Client(c.conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.tp = textproto.NewConn(tlsConn)
+	return nil
+}
+
+// Mail sends MAIL FROM:<from>.
+func (c *Client) Mail(from string) error {
+	if err := c.tp.PrintfLine("MAIL FROM:<%s>", from); err != nil {
+		return err
+	}
+	_, _, err := c.tp.ReadResponse(250)
+	return classifyError(err)
+}
+
+// Rcpt sends RCPT TO:<to>.
+func (c *Client) Rcpt(to string) error {
+	if err := c.tp.PrintfLine("RCPT TO:<%s>", to); err != nil {
+		return err
+	}
+	_, _, err := c.tp.ReadResponse(250)
+	return classifyError(err)
+}
+
+// Data sends the DATA command followed by data, dot-stuffed, and reads the
+// single summary reply (SMTP's DATA semantics; LMTP's per-recipient replies
+// are handled separately by DeliverLMTP).
+func (c *Client) Data(data string) error {
+	if err := c.tp.PrintfLine("DATA"); err != nil {
+		return err
+	}
+	if _, _, err := c.tp.ReadResponse(354); err != nil {
+		return classifyError(err)
+	}
+	dotted := strings.ReplaceAll(data, "\n.", "\n..")
+	if err := c.tp.PrintfLine("%s\r\n.", dotted); err != nil {
+		return err
+	}
+	_, _, err := c.tp.ReadResponse(250)
+	return classifyError(err)
+}
+
+// Quit sends QUIT without waiting for the reply; smtpmini always tears the
+// connection down right after, so there's nothing useful to do with it.
+func (c *Client) Quit() { _ = c.tp.PrintfLine("QUIT") }
+
+// classifySMTPError wraps a response error from the remote MTA as a
+// *queue.PermanentError when it carries a 5xx reply code; everything else
+// (4xx replies, dialing and network errors) is treated as transient and left
+// to retry.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if perr, ok := err.(*textproto.Error); ok && perr.Code/100 == 5 {
+		return &queue.PermanentError{Err: perr}
+	}
+	return err
+}
+
+// sendEnvelope issues MAIL FROM / RCPT TO / DATA over an already-negotiated
+// connection (cleartext or TLS) and reports the first error encountered.
+func (c *Client) sendEnvelope(m *queue.Message) error {
+	// Envelope.From (not From) is what actually goes on the wire, so
+	// null-sender DSNs go out as "MAIL FROM:<>" and can't bounce.
+	if err := c.Mail(m.Envelope.From); err != nil {
+		return err
+	}
+	for _, rcpt := range m.Rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	if err := c.Data(m.Data); err != nil {
+		return err
+	}
+	c.Quit()
+	return nil
+}
@@ -0,0 +1,22 @@
+package smtpclient
+
+import (
+	"net/textproto"
+	"testing"
+
+	"smtpmini/queue"
+)
+
+func TestClassifyErrorPermanent(t *testing.T) {
+	err := classifyError(&textproto.Error{Code: 550, Msg: "no such user"})
+	if _, ok := err.(*queue.PermanentError); !ok {
+		t.Fatalf("expected *queue.PermanentError for a 5xx reply, got %T", err)
+	}
+}
+
+func TestClassifyErrorTransient(t *testing.T) {
+	err := classifyError(&textproto.Error{Code: 450, Msg: "mailbox busy"})
+	if _, ok := err.(*queue.PermanentError); ok {
+		t.Fatalf("4xx reply should not be classified as permanent")
+	}
+}
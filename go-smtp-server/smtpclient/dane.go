@@ -0,0 +1,120 @@
+// dane.go
+package smtpclient
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// ─── DANE / TLSA (RFC 7672) ────────────────────────────────────────────────────
+
+// daneResolver is the DNSSEC-validating resolver used to fetch TLSA records.
+// It must be configured to set the AD bit only on answers it has itself
+// validated, since that bit is the sole signal smtpmini trusts here.
+var daneResolver = &dns.Client{}
+
+// resolverAddr returns the first nameserver from the system's resolver
+// config, which is expected to be a local DNSSEC-validating stub (e.g.
+// unbound or systemd-resolved with DNSSEC enabled).
+func resolverAddr() (string, error) {
+	cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil || len(cfg.Servers) == 0 {
+		return "", fmt.Errorf("dane: no resolver configured: %v", err)
+	}
+	return net.JoinHostPort(cfg.Servers[0], cfg.Port), nil
+}
+
+// lookupTLSARecords queries _25._tcp.<mxHost> and reports the records found
+// together with whether the response carried a validated (AD-bit) answer.
+func lookupTLSARecords(mxHost string) (records []*dns.TLSA, validated bool, err error) {
+	server, err := resolverAddr()
+	if err != nil {
+		return nil, false, err
+	}
+
+	qname := dns.Fqdn(fmt.Sprintf("_25._tcp.%s", mxHost))
+	m := new(dns.Msg)
+	m.SetQuestion(qname, dns.TypeTLSA)
+	m.SetEdns0(4096, true) // DO bit: request DNSSEC data
+
+	resp, _, err := daneResolver.Exchange(m, server)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, false, fmt.Errorf("TLSA lookup for %s: rcode %d", qname, resp.Rcode)
+	}
+	for _, rr := range resp.Answer {
+		if tlsa, ok := rr.(*dns.TLSA); ok {
+			records = append(records, tlsa)
+		}
+	}
+	return records, resp.AuthenticatedData, nil
+}
+
+// verifyDANE checks the server's certificate chain against a set of TLSA
+// records per RFC 7672. It succeeds as soon as any one record matches, using
+// usage-aware matching: DANE-TA (2) may match any certificate the peer
+// presented, not just the leaf, since it pins a CA somewhere in the chain;
+// DANE-EE (3) matches the leaf alone and that match is sufficient trust on
+// its own. PKIX-TA/PKIX-EE (0/1) are not supported: both require the
+// matched certificate to *also* pass ordinary WebPKI path validation, and
+// the caller (deliver.go) sets InsecureSkipVerify so there is no PKIX chain
+// validation to fall back on here — claiming to honor those usages without
+// doing that validation would accept certificates the record alone
+// shouldn't be trusted to vouch for.
+func verifyDANE(chain []*x509.Certificate, records []*dns.TLSA) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("dane: empty certificate chain")
+	}
+	for _, rec := range records {
+		switch rec.Usage {
+		case 2: // DANE-TA: the pinned CA may be any cert in the presented chain
+			for _, cert := range chain {
+				if certMatchesTLSA(cert, rec) {
+					return nil
+				}
+			}
+		case 3: // DANE-EE: the leaf itself is the pinned certificate
+			if certMatchesTLSA(chain[0], rec) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("dane: no TLSA record matched presented certificate")
+}
+
+// certMatchesTLSA reports whether cert is the certificate (or key) pinned by
+// rec, per rec's selector and matching type.
+func certMatchesTLSA(cert *x509.Certificate, rec *dns.TLSA) bool {
+	var target []byte
+	switch rec.Selector {
+	case 0: // full certificate
+		target = cert.Raw
+	case 1: // SubjectPublicKeyInfo
+		target = cert.RawSubjectPublicKeyInfo
+	default:
+		return false
+	}
+
+	var digest []byte
+	switch rec.MatchingType {
+	case 0: // raw, no hash
+		digest = target
+	case 1:
+		sum := sha256.Sum256(target)
+		digest = sum[:]
+	case 2:
+		sum := sha512.Sum512(target)
+		digest = sum[:]
+	default:
+		return false
+	}
+
+	return fmt.Sprintf("%x", digest) == rec.Certificate
+}
@@ -0,0 +1,93 @@
+package smtpclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// genCert returns a minimal self-signed certificate for use as one link in a
+// test chain; DANE matching only ever looks at Raw/RawSubjectPublicKeyInfo,
+// so the cert doesn't need to verify against anything.
+func genCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	templ := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, templ, templ, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse cert: %v", err)
+	}
+	return cert
+}
+
+// tlsaFor builds a selector-0/matching-type-1 (full cert, SHA-256) TLSA
+// record of the given usage pinning cert.
+func tlsaFor(cert *x509.Certificate, usage uint8) *dns.TLSA {
+	sum := sha256.Sum256(cert.Raw)
+	return &dns.TLSA{
+		Usage:        usage,
+		Selector:     0,
+		MatchingType: 1,
+		Certificate:  fmt.Sprintf("%x", sum),
+	}
+}
+
+func TestVerifyDANE_DANEEEMatchesLeaf(t *testing.T) {
+	leaf := genCert(t, "mail.example.com")
+	rec := tlsaFor(leaf, 3) // DANE-EE
+	if err := verifyDANE([]*x509.Certificate{leaf}, []*dns.TLSA{rec}); err != nil {
+		t.Fatalf("verifyDANE: %v", err)
+	}
+}
+
+func TestVerifyDANE_DANETAMatchesIntermediateNotJustLeaf(t *testing.T) {
+	leaf := genCert(t, "mail.example.com")
+	intermediate := genCert(t, "intermediate-ca")
+	rec := tlsaFor(intermediate, 2) // DANE-TA pinning the intermediate, not the leaf
+
+	if err := verifyDANE([]*x509.Certificate{leaf, intermediate}, []*dns.TLSA{rec}); err != nil {
+		t.Fatalf("verifyDANE should match a DANE-TA record against any cert in the chain: %v", err)
+	}
+}
+
+func TestVerifyDANE_PKIXUsagesUnsupported(t *testing.T) {
+	leaf := genCert(t, "mail.example.com")
+	// Usage 0/1 (PKIX-TA/PKIX-EE) require WebPKI validation on top of the
+	// TLSA match, which verifyDANE doesn't perform, so a record matching
+	// the leaf by content alone must not be trusted.
+	for _, usage := range []uint8{0, 1} {
+		rec := tlsaFor(leaf, usage)
+		if err := verifyDANE([]*x509.Certificate{leaf}, []*dns.TLSA{rec}); err == nil {
+			t.Fatalf("usage %d: verifyDANE should not accept a match with no PKIX validation", usage)
+		}
+	}
+}
+
+func TestVerifyDANE_NoMatch(t *testing.T) {
+	leaf := genCert(t, "mail.example.com")
+	other := genCert(t, "someone-else.example.com")
+	rec := tlsaFor(other, 3)
+	if err := verifyDANE([]*x509.Certificate{leaf}, []*dns.TLSA{rec}); err == nil {
+		t.Fatalf("verifyDANE should fail when no record matches any presented certificate")
+	}
+}
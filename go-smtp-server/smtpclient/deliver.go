@@ -0,0 +1,106 @@
+// deliver.go
+package smtpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"smtpmini/queue"
+)
+
+// Deliver attempts one delivery of m: it resolves the recipient domain's MX,
+// applies MTA-STS/DANE policy, connects, negotiates STARTTLS if offered, and
+// sends the envelope. A 5xx reply anywhere along the way comes back wrapped
+// in a *queue.PermanentError so the caller's retry scheduler knows not to
+// bother trying again.
+func Deliver(m *queue.Message) error {
+	// 1) MX lookup
+	domain := m.Rcpts[0][strings.LastIndexByte(m.Rcpts[0], '@')+1:]
+	var host string
+	if mx, err := net.LookupMX(domain); err == nil && len(mx) > 0 {
+		host = mx[0].Host
+	}
+	if host == "" {
+		return fmt.Errorf("no MX found for %s", domain)
+	}
+
+	// 1a) MTA-STS: fetch (or reuse the cached) policy and, in enforce mode,
+	// restrict delivery to MX hosts the policy actually names.
+	stsMode := m.Policy.MTASTSMode
+	policy, policyErr := fetchMTASTSPolicy(domain)
+	if policyErr == nil {
+		if stsMode == "" {
+			stsMode = policy.Mode
+		}
+		if stsMode == "enforce" && !mxMatchesPolicy(host, policy.MXPatterns) {
+			return fmt.Errorf("mta-sts: MX %s for %s not covered by policy, deferring", host, domain)
+		}
+	} else if stsMode == "enforce" {
+		return fmt.Errorf("mta-sts: enforce requested but no policy fetchable for %s: %w", domain, policyErr)
+	}
+
+	addr := net.JoinHostPort(host, "25")
+
+	// 2) connect
+	c, err := Dial("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// 3) EHLO + STARTTLS detection / upgrade
+	supportsTLS, err := c.Ehlo(clientHostname)
+	if err != nil {
+		return err
+	}
+	if !supportsTLS {
+		if m.Policy.RequireTLS || stsMode == "enforce" {
+			return fmt.Errorf("%s does not offer STARTTLS but TLS is required, deferring", host)
+		}
+		return c.sendEnvelope(m)
+	}
+
+	// DANE: checked automatically whenever TLSA records resolve and are
+	// DNSSEC-validated, the same way MTA-STS policy is always fetched above —
+	// no opt-in flag is needed to turn the lookup on. When records are
+	// found, verification happens against the chain below instead of (or in
+	// addition to) PKIX.
+	daneRecords, daneValidated, daneErr := lookupTLSARecords(host)
+	daneActive := daneErr == nil && daneValidated && len(daneRecords) > 0
+
+	tlsCfg := &tls.Config{ServerName: host}
+	if daneActive {
+		// Verification is done manually in VerifyPeerCertificate so a DANE
+		// match can stand in for (or alongside) the PKIX chain.
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			chain := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				chain = append(chain, cert)
+			}
+			return verifyDANE(chain, daneRecords)
+		}
+	} else if stsMode == "enforce" && policyErr == nil {
+		// Plain PKIX verification against the MX hostname, which is exactly
+		// what a non-InsecureSkipVerify tls.Config already does.
+	} else if m.Policy.DANEEnabled && !daneActive {
+		return fmt.Errorf("dane: required but TLSA records for %s could not be validated, deferring", host)
+	}
+
+	if err := c.StartTLS(tlsCfg); err != nil {
+		return err
+	}
+	if _, err := c.Ehlo(clientHostname); err != nil {
+		return err
+	}
+
+	return c.sendEnvelope(m)
+}
@@ -0,0 +1,98 @@
+// lmtp.go
+package smtpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"smtpmini/queue"
+	"smtpmini/smtpsrv"
+)
+
+// DeliverLMTP delivers m over LMTP to the given local delivery agent
+// (typically a Unix domain socket such as Dovecot's LMTP listener), reusing
+// the SMTP client's envelope/DATA plumbing since LMTP's DATA phase is
+// byte-for-byte identical to SMTP's up to the reply.
+func DeliverLMTP(sockPath string, m *queue.Message) ([]smtpsrv.DeliveryResult, error) {
+	network := "unix"
+	if _, _, err := net.SplitHostPort(sockPath); err == nil {
+		network = "tcp"
+	}
+
+	c, err := Dial(network, sockPath, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if err := c.Lhlo(clientHostname); err != nil {
+		return nil, err
+	}
+	if err := c.Mail(m.Envelope.From); err != nil {
+		return nil, err
+	}
+
+	// A real LMTP peer (Dovecot and friends) rejects individual mailboxes at
+	// RCPT TO, so one recipient's 5xx must not abort delivery to the rest —
+	// that per-recipient partial-success case is exactly what LMTP's status
+	// design (and DeliverLMTP's return type) exists to carry. Failures are
+	// recorded in place and only the accepted recipients go on to DATA.
+	results := make([]smtpsrv.DeliveryResult, len(m.Rcpts))
+	accepted := make([]string, 0, len(m.Rcpts))
+	for i, rcpt := range m.Rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			results[i] = smtpsrv.DeliveryResult{Rcpt: rcpt, Code: rcptErrorCode(err), Message: err.Error()}
+			continue
+		}
+		accepted = append(accepted, rcpt)
+	}
+	if len(accepted) == 0 {
+		c.Quit()
+		return results, nil
+	}
+
+	if err := c.tp.PrintfLine("DATA"); err != nil {
+		return nil, err
+	}
+	if _, _, err := c.tp.ReadResponse(354); err != nil {
+		return nil, classifyError(err)
+	}
+	dotted := strings.ReplaceAll(m.Data, "\n.", "\n..")
+	if err := c.tp.PrintfLine("%s\r\n.", dotted); err != nil {
+		return nil, err
+	}
+
+	// LMTP answers DATA with one status line per accepted recipient, in RCPT
+	// order, and each may carry a different code, so these can't be read
+	// with ReadResponse's single expected-code check.
+	for i, rcpt := range m.Rcpts {
+		if results[i].Rcpt != "" {
+			continue // already failed at RCPT TO, above
+		}
+		line, err := c.tp.ReadLine()
+		if err != nil {
+			return results, err
+		}
+		code, msg, _ := strings.Cut(line, " ")
+		var codeNum int
+		fmt.Sscanf(code, "%d", &codeNum)
+		results[i] = smtpsrv.DeliveryResult{Rcpt: rcpt, Code: codeNum, Message: msg}
+	}
+	c.Quit()
+	return results, nil
+}
+
+// rcptErrorCode extracts the SMTP reply code a RCPT TO failure carried, for
+// recording in that recipient's DeliveryResult; errors with no reply code
+// (dial/network failures) are reported as a generic 4xx so the caller
+// treats them as transient rather than a hard bounce.
+func rcptErrorCode(err error) int {
+	var perr *textproto.Error
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	return 421
+}
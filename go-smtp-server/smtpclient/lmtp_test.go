@@ -0,0 +1,87 @@
+package smtpclient
+
+import (
+	"io"
+	"testing"
+
+	"smtpmini/queue"
+	"smtpmini/smtpsrv"
+)
+
+// rejectingLMTPBackend rejects any recipient in reject at RCPT TO, the way
+// Dovecot rejects an individual unknown mailbox, and accepts the rest.
+type rejectingLMTPBackend struct{ reject map[string]bool }
+
+func (b *rejectingLMTPBackend) NewSession(conn *smtpsrv.Conn) (smtpsrv.Session, error) {
+	return &rejectingLMTPSession{backend: b}, nil
+}
+
+type rejectingLMTPSession struct {
+	backend *rejectingLMTPBackend
+	rcpts   []string
+}
+
+func (s *rejectingLMTPSession) Mail(from string, opts *smtpsrv.MailOptions) error { return nil }
+
+func (s *rejectingLMTPSession) Rcpt(to string, opts *smtpsrv.RcptOptions) error {
+	if s.backend.reject[to] {
+		return &queue.PermanentError{Err: errRcptRejected}
+	}
+	s.rcpts = append(s.rcpts, to)
+	return nil
+}
+
+func (s *rejectingLMTPSession) Data(r io.Reader) ([]smtpsrv.DeliveryResult, error) {
+	if _, err := io.ReadAll(r); err != nil {
+		return nil, err
+	}
+	results := make([]smtpsrv.DeliveryResult, len(s.rcpts))
+	for i, rcpt := range s.rcpts {
+		results[i] = smtpsrv.DeliveryResult{Rcpt: rcpt, Code: 250, Message: "delivered"}
+	}
+	return results, nil
+}
+
+func (s *rejectingLMTPSession) Reset()        {}
+func (s *rejectingLMTPSession) Logout() error { return nil }
+
+type rcptRejectedError struct{}
+
+func (rcptRejectedError) Error() string { return "unknown mailbox" }
+
+var errRcptRejected error = rcptRejectedError{}
+
+// TestDeliverLMTPPartialRcptFailure exercises the multi-recipient,
+// partial-success case LMTP's per-recipient status design exists for: one
+// recipient rejected at RCPT TO must not abort delivery to the others.
+func TestDeliverLMTPPartialRcptFailure(t *testing.T) {
+	backend := &rejectingLMTPBackend{reject: map[string]bool{"bad@y": true}}
+	stop, addr, err := smtpsrv.New(smtpsrv.WithBackend(backend)).StartLMTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start lmtp: %v", err)
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	m := &queue.Message{
+		Envelope: queue.Envelope{From: "a@b"},
+		Rcpts:    []string{"good@x", "bad@y", "good2@x"},
+		Data:     "Subject: hi\r\n\r\nbody\r\n",
+	}
+
+	results, err := DeliverLMTP(addr, m)
+	if err != nil {
+		t.Fatalf("DeliverLMTP: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("want 3 results, got %d: %v", len(results), results)
+	}
+	if results[0].Rcpt != "good@x" || results[0].Code != 250 {
+		t.Fatalf("good@x: got %+v, want 250", results[0])
+	}
+	if results[1].Rcpt != "bad@y" || results[1].Code/100 != 5 {
+		t.Fatalf("bad@y: got %+v, want a 5xx code", results[1])
+	}
+	if results[2].Rcpt != "good2@x" || results[2].Code != 250 {
+		t.Fatalf("good2@x: got %+v, want 250", results[2])
+	}
+}
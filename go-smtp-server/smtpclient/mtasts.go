@@ -0,0 +1,175 @@
+// mtasts.go
+package smtpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ─── MTA-STS policy ────────────────────────────────────────────────────────────
+
+// MTASTSPolicy is the parsed form of a domain's
+// https://mta-sts.<domain>/.well-known/mta-sts.txt document.
+type MTASTSPolicy struct {
+	Version    string        `json:"version"`
+	Mode       string        `json:"mode"` // "enforce", "testing", or "none"
+	MXPatterns []string      `json:"mx"`
+	MaxAge     time.Duration `json:"max_age"`
+	FetchedAt  time.Time     `json:"fetched_at"`
+	PolicyID   string        `json:"policy_id"` // from the _mta-sts TXT record, for cache invalidation
+}
+
+func (p *MTASTSPolicy) expired() bool { return time.Since(p.FetchedAt) > p.MaxAge }
+
+const mtaSTSDir = "spool/mta-sts"
+
+func init() { _ = os.MkdirAll(mtaSTSDir, 0o755) }
+
+// mtaSTSPolicyPath returns the on-disk cache location for a domain's policy.
+func mtaSTSPolicyPath(domain string) string {
+	return filepath.Join(mtaSTSDir, domain+".json")
+}
+
+// lookupPolicyID reads the _mta-sts.<domain> TXT record, which carries a
+// monotonically increasing "v=STSv1; id=<opaque>" value. A changed id
+// invalidates any cached policy regardless of max_age.
+func lookupPolicyID(domain string) (string, error) {
+	txts, err := net.LookupTXT("_mta-sts." + domain)
+	if err != nil {
+		return "", err
+	}
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, "v=STSv1") {
+			continue
+		}
+		for _, field := range strings.Split(txt, ";") {
+			field = strings.TrimSpace(field)
+			if id, ok := strings.CutPrefix(field, "id="); ok {
+				return id, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no STSv1 TXT record for %s", domain)
+}
+
+// fetchMTASTSPolicy retrieves and parses the policy document over HTTPS,
+// verifying it against the current policy id and updating the on-disk cache.
+func fetchMTASTSPolicy(domain string) (*MTASTSPolicy, error) {
+	policyID, err := lookupPolicyID(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, err := loadCachedMTASTSPolicy(domain); err == nil {
+		if cached.PolicyID == policyID && !cached.expired() {
+			return cached, nil
+		}
+	}
+
+	url := "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mta-sts: unexpected status %d for %s", resp.StatusCode, url)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := parseMTASTSPolicy(body)
+	if err != nil {
+		return nil, err
+	}
+	policy.PolicyID = policyID
+	policy.FetchedAt = time.Now()
+
+	_ = cacheMTASTSPolicy(domain, policy)
+	return policy, nil
+}
+
+// parseMTASTSPolicy parses the "key: value" lines of an mta-sts.txt document.
+func parseMTASTSPolicy(body []byte) (*MTASTSPolicy, error) {
+	p := &MTASTSPolicy{MaxAge: 24 * time.Hour}
+	for _, line := range strings.Split(string(body), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "version":
+			p.Version = value
+		case "mode":
+			p.Mode = value
+		case "mx":
+			p.MXPatterns = append(p.MXPatterns, value)
+		case "max_age":
+			if secs, err := strconv.Atoi(value); err == nil {
+				p.MaxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	if p.Version == "" || p.Mode == "" {
+		return nil, fmt.Errorf("mta-sts: missing version or mode")
+	}
+	return p, nil
+}
+
+func loadCachedMTASTSPolicy(domain string) (*MTASTSPolicy, error) {
+	b, err := os.ReadFile(mtaSTSPolicyPath(domain))
+	if err != nil {
+		return nil, err
+	}
+	var p MTASTSPolicy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func cacheMTASTSPolicy(domain string, p *MTASTSPolicy) error {
+	f, err := os.Create(mtaSTSPolicyPath(domain))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(p)
+}
+
+// mxMatchesPolicy reports whether mxHost satisfies one of the policy's "mx:"
+// patterns, which may carry a single leading "*." wildcard label.
+func mxMatchesPolicy(mxHost string, patterns []string) bool {
+	mxHost = strings.TrimSuffix(strings.ToLower(mxHost), ".")
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+		if rest, found := strings.CutPrefix(pattern, "*."); found {
+			// RFC 8461 §4.1: "*." covers exactly one DNS label, so the
+			// remainder must have one more label than the wildcard suffix.
+			// path.Match's "*" doesn't stop at ".", so it isn't safe to use
+			// here: it would let *.example.com match
+			// evil.attacker.example.com too.
+			if strings.HasSuffix(mxHost, "."+rest) &&
+				strings.Count(mxHost, ".") == strings.Count(rest, ".")+1 {
+				return true
+			}
+			continue
+		}
+		if mxHost == pattern {
+			return true
+		}
+	}
+	return false
+}
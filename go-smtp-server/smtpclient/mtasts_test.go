@@ -0,0 +1,19 @@
+package smtpclient
+
+import "testing"
+
+func TestMxMatchesPolicyWildcardIsSingleLabel(t *testing.T) {
+	patterns := []string{"*.example.com"}
+
+	if !mxMatchesPolicy("mail.example.com", patterns) {
+		t.Fatalf("mail.example.com should match *.example.com")
+	}
+	// RFC 8461 §4.1: "*." covers exactly one label, so a second-level
+	// subdomain must not match.
+	if mxMatchesPolicy("evil.attacker.example.com", patterns) {
+		t.Fatalf("evil.attacker.example.com should not match *.example.com")
+	}
+	if mxMatchesPolicy("example.com", patterns) {
+		t.Fatalf("example.com itself should not match the *.example.com wildcard")
+	}
+}
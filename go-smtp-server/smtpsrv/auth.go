@@ -0,0 +1,219 @@
+// auth.go
+package smtpsrv
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ─── authenticator ────────────────────────────────────────────────────────────
+
+// Authenticator looks up the password hash for a username so the server can
+// verify SMTP AUTH attempts without needing to know about the storage layer.
+type Authenticator interface {
+	// Lookup returns the stored password (or password hash, for mechanisms
+	// that need the plaintext to compute a keyed hash) for username.
+	// A non-nil err means the user is unknown or the lookup failed.
+	Lookup(username string) (passwordHash string, err error)
+}
+
+// ─── per-IP failure rate limiting ─────────────────────────────────────────────
+
+// authLimiter blunts brute-force AUTH attempts by blocking an address once it
+// has accumulated too many failures within the window.
+type authLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+const (
+	maxAuthFailures = 5
+	authFailWindow  = 1 * time.Minute
+)
+
+func newAuthLimiter() *authLimiter {
+	return &authLimiter{failures: make(map[string][]time.Time)}
+}
+
+// allowed reports whether addr may attempt AUTH right now.
+func (l *authLimiter) allowed(addr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := time.Now().Add(-authFailWindow)
+	kept := l.failures[addr][:0]
+	for _, t := range l.failures[addr] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	l.failures[addr] = kept
+	return len(kept) < maxAuthFailures
+}
+
+func (l *authLimiter) recordFailure(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures[addr] = append(l.failures[addr], time.Now())
+}
+
+// limiterKey reduces a net.Conn's RemoteAddr to just its host, since
+// RemoteAddr().String() is "ip:port" and a brute-forcer that reconnects for
+// every guess gets a fresh ephemeral port each time, never accumulating
+// failures against the same key.
+func limiterKey(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// ─── challenge generation ─────────────────────────────────────────────────────
+
+func defaultHostname() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return "smtpmini"
+	}
+	return h
+}
+
+// cramChallenge builds an RFC 2195 challenge of the form "<pid.timestamp@hostname>".
+func cramChallenge(hostname string) string {
+	return fmt.Sprintf("<%d.%d@%s>", os.Getpid(), time.Now().UnixNano(), hostname)
+}
+
+// ─── mechanism decoders ───────────────────────────────────────────────────────
+
+// decodePlain splits a base64-decoded AUTH PLAIN response into
+// authzid\0authcid\0password.
+func decodePlain(b []byte) (authzid, authcid, password string, err error) {
+	parts := strings.Split(string(b), "\x00")
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("malformed PLAIN response")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// decodeCramResponse splits the client's CRAM-MD5 response into the
+// "user hex-hmac" pair.
+func decodeCramResponse(b []byte) (user, hexHMAC string, err error) {
+	parts := strings.SplitN(string(b), " ", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed CRAM-MD5 response")
+	}
+	return parts[0], parts[1], nil
+}
+
+// verifyCramMD5 recomputes the HMAC-MD5 digest of challenge keyed on password
+// and compares it in constant time against the hex digest the client sent.
+func verifyCramMD5(password, challenge, gotHexDigest string) bool {
+	mac := hmac.New(md5.New, []byte(password))
+	mac.Write([]byte(challenge))
+	want := fmt.Sprintf("%x", mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(want), []byte(gotHexDigest)) == 1
+}
+
+// b64 / unb64 are small wrappers so the AUTH handlers read top to bottom.
+func b64(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+func unb64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
+
+// ─── protocol glue ─────────────────────────────────────────────────────────────
+
+// handleAUTH drives the AUTH PLAIN/LOGIN/CRAM-MD5 challenge-response exchange
+// and returns the authenticated username on success.
+func (srv *Server) handleAUTH(r *bufio.Scanner, w *bufio.Writer, arg string) (string, error) {
+	mech, rest, _ := strings.Cut(strings.TrimSpace(arg), " ")
+	mech = strings.ToUpper(mech)
+
+	switch mech {
+	case "PLAIN":
+		payload := rest
+		if payload == "" {
+			writeLine(w, "334 ")
+			if !r.Scan() {
+				return "", fmt.Errorf("client closed connection during AUTH PLAIN")
+			}
+			payload = r.Text()
+		}
+		raw, err := unb64(payload)
+		if err != nil {
+			return "", err
+		}
+		_, user, pass, err := decodePlain(raw)
+		if err != nil {
+			return "", err
+		}
+		return verifyPassword(srv.auth, user, pass)
+
+	case "LOGIN":
+		writeLine(w, "334 "+b64("Username:"))
+		if !r.Scan() {
+			return "", fmt.Errorf("client closed connection during AUTH LOGIN")
+		}
+		userB, err := unb64(r.Text())
+		if err != nil {
+			return "", err
+		}
+		writeLine(w, "334 "+b64("Password:"))
+		if !r.Scan() {
+			return "", fmt.Errorf("client closed connection during AUTH LOGIN")
+		}
+		passB, err := unb64(r.Text())
+		if err != nil {
+			return "", err
+		}
+		return verifyPassword(srv.auth, string(userB), string(passB))
+
+	case "CRAM-MD5":
+		challenge := cramChallenge(srv.hostname)
+		writeLine(w, "334 "+b64(challenge))
+		if !r.Scan() {
+			return "", fmt.Errorf("client closed connection during AUTH CRAM-MD5")
+		}
+		raw, err := unb64(r.Text())
+		if err != nil {
+			return "", err
+		}
+		user, gotHex, err := decodeCramResponse(raw)
+		if err != nil {
+			return "", err
+		}
+		passwordHash, err := srv.auth.Lookup(user)
+		if err != nil {
+			return "", err
+		}
+		if !verifyCramMD5(passwordHash, challenge, gotHex) {
+			return "", fmt.Errorf("CRAM-MD5 digest mismatch for %s", user)
+		}
+		return user, nil
+
+	default:
+		return "", fmt.Errorf("unsupported AUTH mechanism %q", mech)
+	}
+}
+
+// verifyPassword checks a plaintext password against the hash Lookup
+// returns. smtpmini stores passwords in plain form for simplicity, so the
+// comparison is a constant-time byte comparison rather than a KDF verify.
+func verifyPassword(auth Authenticator, user, password string) (string, error) {
+	want, err := auth.Lookup(user)
+	if err != nil {
+		return "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(want), []byte(password)) != 1 {
+		return "", fmt.Errorf("password mismatch for %s", user)
+	}
+	return user, nil
+}
@@ -0,0 +1,141 @@
+package smtpsrv
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+type mapAuthenticator map[string]string
+
+func (m mapAuthenticator) Lookup(username string) (string, error) {
+	pass, ok := m[username]
+	if !ok {
+		return "", fmt.Errorf("unknown user %q", username)
+	}
+	return pass, nil
+}
+
+func TestAuthPlainRequiredBeforeMail(t *testing.T) {
+	auth := mapAuthenticator{"alice": "hunter2"}
+	stop, addr, err := New(WithAuthenticator(auth)).Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer raw.Close()
+	r := bufio.NewReader(raw)
+	expect(t, readLine(r, t), "220")
+
+	_, _ = raw.Write([]byte("EHLO client\r\n"))
+	for {
+		line := readLine(r, t)
+		if line[:4] == "250-" {
+			continue
+		}
+		break
+	}
+
+	_, _ = raw.Write([]byte("STARTTLS\r\n"))
+	expect(t, readLine(r, t), "220")
+
+	tlsConn := tls.Client(raw, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("TLS handshake: %v", err)
+	}
+	rTLS := bufio.NewReader(tlsConn)
+
+	_, _ = tlsConn.Write([]byte("EHLO client\r\n"))
+	for {
+		line := readLine(rTLS, t)
+		if line[:4] == "250-" {
+			continue
+		}
+		break
+	}
+
+	// MAIL FROM before AUTH must be rejected when an Authenticator is wired up.
+	_, _ = tlsConn.Write([]byte("MAIL FROM:<a@b>\r\n"))
+	expect(t, readLine(rTLS, t), "530")
+
+	_, _ = tlsConn.Write([]byte("AUTH PLAIN " + b64("\x00alice\x00hunter2") + "\r\n"))
+	expect(t, readLine(rTLS, t), "235")
+
+	_, _ = tlsConn.Write([]byte("MAIL FROM:<a@b>\r\n"))
+	expect(t, readLine(rTLS, t), "250")
+}
+
+// dialAndAttemptAuth opens a brand-new TCP connection (a fresh ephemeral
+// port, as a reconnecting brute-forcer would use) and returns the server's
+// reply to a single bad AUTH PLAIN attempt over it.
+func dialAndAttemptAuth(t *testing.T, addr string) string {
+	t.Helper()
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer raw.Close()
+	r := bufio.NewReader(raw)
+	expect(t, readLine(r, t), "220")
+
+	_, _ = raw.Write([]byte("EHLO client\r\n"))
+	for {
+		line := readLine(r, t)
+		if line[:4] == "250-" {
+			continue
+		}
+		break
+	}
+
+	_, _ = raw.Write([]byte("STARTTLS\r\n"))
+	expect(t, readLine(r, t), "220")
+
+	tlsConn := tls.Client(raw, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("TLS handshake: %v", err)
+	}
+	rTLS := bufio.NewReader(tlsConn)
+
+	_, _ = tlsConn.Write([]byte("EHLO client\r\n"))
+	for {
+		line := readLine(rTLS, t)
+		if line[:4] == "250-" {
+			continue
+		}
+		break
+	}
+
+	_, _ = tlsConn.Write([]byte("AUTH PLAIN " + b64("\x00alice\x00wrongpass") + "\r\n"))
+	return readLine(rTLS, t)
+}
+
+// TestAuthRateLimitsPerSourceIP reconnects on a new ephemeral port for every
+// attempt, the way a real brute-forcer does, and asserts the limiter still
+// blocks the (maxAuthFailures+1)th attempt — it must be keyed on the
+// connection's IP, not its ip:port RemoteAddr.
+func TestAuthRateLimitsPerSourceIP(t *testing.T) {
+	auth := mapAuthenticator{"alice": "hunter2"}
+	stop, addr, err := New(WithAuthenticator(auth)).Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	for i := 0; i < maxAuthFailures; i++ {
+		if resp := dialAndAttemptAuth(t, addr); !strings.HasPrefix(resp, "535") {
+			t.Fatalf("attempt %d: got %q, want 535", i+1, resp)
+		}
+	}
+
+	if resp := dialAndAttemptAuth(t, addr); !strings.HasPrefix(resp, "454") {
+		t.Fatalf("attempt %d: got %q, want 454 (rate-limited)", maxAuthFailures+1, resp)
+	}
+}
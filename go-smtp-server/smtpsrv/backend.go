@@ -0,0 +1,168 @@
+// backend.go
+package smtpsrv
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ─── connection handle ─────────────────────────────────────────────────────────
+
+// Conn is the subset of connection state a Backend is allowed to see. It
+// deliberately hides the raw net.Conn so backends can't bypass the protocol
+// layer (e.g. write directly to the socket).
+type Conn struct {
+	netConn  net.Conn
+	tlsState *tls.ConnectionState
+}
+
+// RemoteAddr returns the client's network address.
+func (c *Conn) RemoteAddr() net.Addr { return c.netConn.RemoteAddr() }
+
+// TLSConnectionState reports the negotiated TLS state, if the connection has
+// been upgraded via STARTTLS.
+func (c *Conn) TLSConnectionState() (tls.ConnectionState, bool) {
+	if c.tlsState == nil {
+		return tls.ConnectionState{}, false
+	}
+	return *c.tlsState, true
+}
+
+// ─── extension parameters ──────────────────────────────────────────────────────
+
+// MailOptions carries the parsed parameters of a MAIL FROM command (the
+// ESMTP extensions smtpmini understands).
+type MailOptions struct {
+	Size       int    // SIZE=<n>, 0 if unset
+	Body       string // BODY=7BIT or BODY=8BITMIME, "" if unset
+	SMTPUTF8   bool   // SMTPUTF8 present
+	RequireTLS bool   // REQUIRETLS present
+	Auth       string // AUTH=<xtext>, "" if unset
+}
+
+// RcptOptions carries the parsed parameters of a RCPT TO command.
+type RcptOptions struct {
+	Notify []string // NOTIFY=SUCCESS,FAILURE,DELAY,NEVER
+}
+
+// parseMailFrom splits "FROM:<addr> PARAM=VALUE ..." into the bare address
+// and its parsed MailOptions.
+func parseMailFrom(arg string) (addr string, opts *MailOptions, err error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 || !strings.HasPrefix(strings.ToUpper(fields[0]), "FROM:") {
+		return "", nil, errSyntax("MAIL FROM:<address>")
+	}
+	addr = stripAddr(fields[0][len("FROM:"):])
+
+	opts = &MailOptions{}
+	for _, param := range fields[1:] {
+		key, value, _ := strings.Cut(strings.ToUpper(param), "=")
+		switch key {
+		case "SIZE":
+			opts.Size, _ = strconv.Atoi(value)
+		case "BODY":
+			opts.Body = value
+		case "SMTPUTF8":
+			opts.SMTPUTF8 = true
+		case "REQUIRETLS":
+			opts.RequireTLS = true
+		case "AUTH":
+			opts.Auth = value
+		}
+	}
+	return addr, opts, nil
+}
+
+// parseRcptTo splits "TO:<addr> PARAM=VALUE ..." into the bare address and
+// its parsed RcptOptions.
+func parseRcptTo(arg string) (addr string, opts *RcptOptions, err error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 || !strings.HasPrefix(strings.ToUpper(fields[0]), "TO:") {
+		return "", nil, errSyntax("RCPT TO:<address>")
+	}
+	addr = stripAddr(fields[0][len("TO:"):])
+
+	opts = &RcptOptions{}
+	for _, param := range fields[1:] {
+		key, value, _ := strings.Cut(strings.ToUpper(param), "=")
+		if key == "NOTIFY" {
+			opts.Notify = strings.Split(value, ",")
+		}
+	}
+	return addr, opts, nil
+}
+
+type errSyntax string
+
+func (e errSyntax) Error() string { return "Syntax: " + string(e) }
+
+// ─── Backend / Session ──────────────────────────────────────────────────────────
+
+// Backend decouples the SMTP protocol state machine in Server.handleConn
+// from however messages actually get stored or forwarded. Modeled on the
+// go-smtp Backend/Session split so alternative backends (in-memory for
+// tests, REST-forwarding, IMAP injection, ...) can be plugged in without
+// touching this package.
+type Backend interface {
+	NewSession(conn *Conn) (Session, error)
+}
+
+// Session is the per-connection state a Backend maintains across one or more
+// messages. Its methods are called in lock-step with the SMTP commands
+// Server.handleConn receives.
+type Session interface {
+	Mail(from string, opts *MailOptions) error
+	Rcpt(to string, opts *RcptOptions) error
+	// Data consumes the message body and reports one DeliveryResult per
+	// recipient accepted via Rcpt, in the order they were given. SMTP mode
+	// collapses these into a single summary reply; LMTP mode reports each
+	// one individually, per RFC 2033.
+	Data(r io.Reader) ([]DeliveryResult, error)
+	Reset()
+	Logout() error
+}
+
+// DeliveryResult is the per-recipient outcome of a DATA command, used to
+// answer LMTP's "one status line per RCPT" requirement. SMTP mode collapses
+// these into a single summary reply.
+type DeliveryResult struct {
+	Rcpt    string
+	Code    int
+	Message string
+}
+
+// ─── default backend ────────────────────────────────────────────────────────────
+
+// discardBackend accepts every message and throws it away. It's the
+// zero-value default so a Server is useful standalone, without pulling in
+// any disk-spool or queueing code.
+type discardBackend struct{}
+
+func (discardBackend) NewSession(conn *Conn) (Session, error) { return &discardSession{}, nil }
+
+type discardSession struct{ rcpts []string }
+
+func (s *discardSession) Mail(from string, opts *MailOptions) error { return nil }
+
+func (s *discardSession) Rcpt(to string, opts *RcptOptions) error {
+	s.rcpts = append(s.rcpts, to)
+	return nil
+}
+
+func (s *discardSession) Data(r io.Reader) ([]DeliveryResult, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+	results := make([]DeliveryResult, len(s.rcpts))
+	for i, rcpt := range s.rcpts {
+		results[i] = DeliveryResult{Rcpt: rcpt, Code: 250, Message: "queued"}
+	}
+	return results, nil
+}
+
+func (s *discardSession) Reset() { s.rcpts = nil }
+
+func (s *discardSession) Logout() error { return nil }
@@ -0,0 +1,104 @@
+package smtpsrv
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// recordingBackend is a mock Backend that records every call made to it,
+// for use in tests that don't want to touch the disk spool.
+type recordingBackend struct {
+	calls []string
+}
+
+func (b *recordingBackend) NewSession(conn *Conn) (Session, error) {
+	b.calls = append(b.calls, "NewSession")
+	return &recordingSession{backend: b}, nil
+}
+
+type recordingSession struct {
+	backend *recordingBackend
+}
+
+func (s *recordingSession) Mail(from string, opts *MailOptions) error {
+	s.backend.calls = append(s.backend.calls, "Mail:"+from)
+	return nil
+}
+
+func (s *recordingSession) Rcpt(to string, opts *RcptOptions) error {
+	s.backend.calls = append(s.backend.calls, "Rcpt:"+to)
+	return nil
+}
+
+func (s *recordingSession) Data(r io.Reader) ([]DeliveryResult, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s.backend.calls = append(s.backend.calls, "Data:"+string(body))
+	return []DeliveryResult{{Rcpt: "c@d", Code: 250, Message: "queued"}}, nil
+}
+
+func (s *recordingSession) Reset() { s.backend.calls = append(s.backend.calls, "Reset") }
+
+func (s *recordingSession) Logout() error {
+	s.backend.calls = append(s.backend.calls, "Logout")
+	return nil
+}
+
+func TestHandleConnUsesMockBackend(t *testing.T) {
+	backend := &recordingBackend{}
+	stop, addr, err := New(WithBackend(backend)).Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer raw.Close()
+	r := bufio.NewReader(raw)
+	expect(t, readLine(r, t), "220")
+
+	_, _ = raw.Write([]byte("EHLO client\r\n"))
+	for {
+		line := readLine(r, t)
+		if line[:4] == "250-" {
+			continue
+		}
+		break
+	}
+	_, _ = raw.Write([]byte("MAIL FROM:<a@b>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("RCPT TO:<c@d>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("DATA\r\n"))
+	expect(t, readLine(r, t), "354")
+	_, _ = raw.Write([]byte("hello\r\n.\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("QUIT\r\n"))
+	expect(t, readLine(r, t), "221")
+
+	raw.Close()
+	// Logout happens on connection teardown; give the server goroutine a
+	// moment to run its deferred cleanup before asserting.
+	time.Sleep(50 * time.Millisecond)
+
+	want := []string{"NewSession", "Reset", "Mail:a@b", "Rcpt:c@d", "Data:hello\r\n", "Reset"}
+	if len(backend.calls) < len(want) {
+		t.Fatalf("got calls %v, want at least %v", backend.calls, want)
+	}
+	for i, w := range want {
+		if backend.calls[i] != w {
+			t.Fatalf("call %d: got %q, want %q (all calls: %v)", i, backend.calls[i], w, backend.calls)
+		}
+	}
+	if last := backend.calls[len(backend.calls)-1]; last != "Logout" {
+		t.Fatalf("expected final call to be Logout, got %q (all calls: %v)", last, backend.calls)
+	}
+}
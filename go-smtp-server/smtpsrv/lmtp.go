@@ -0,0 +1,77 @@
+// lmtp.go
+package smtpsrv
+
+import (
+	"fmt"
+	"log"
+	"net"
+)
+
+// ─── LMTP (RFC 2033) ────────────────────────────────────────────────────────────
+
+// StartLMTP launches an LMTP listener, speaking RFC 2033 instead of SMTP.
+// addr is treated as a filesystem path (and listened on as a Unix domain
+// socket) whenever it doesn't look like a host:port pair, which is the usual
+// way to hand mail to a local delivery agent such as Dovecot.
+func (srv *Server) StartLMTP(addr string) (stop func() error, actualAddr string, err error) {
+	network := "tcp"
+	if _, _, splitErr := net.SplitHostPort(addr); splitErr != nil {
+		network = "unix"
+	}
+
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	go func() {
+		log.Printf("smtpmini LMTP listening on %s (%s)", ln.Addr(), network)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // Listener closed
+			}
+			go srv.handleConn(conn, nil, nil, true)
+		}
+	}()
+
+	return ln.Close, ln.Addr().String(), nil
+}
+
+// lmtpStatusLine renders one DeliveryResult as the SMTP-style reply line
+// LMTP emits per recipient, e.g. "250 2.1.5 <a@x> delivered".
+func lmtpStatusLine(res DeliveryResult) string {
+	msg := res.Message
+	if msg == "" {
+		if res.Code/100 == 2 {
+			msg = "delivered"
+		} else {
+			msg = "delivery failed"
+		}
+	}
+	return fmt.Sprintf("%d %s <%s> %s", res.Code, enhancedStatusCode(res.Code), res.Rcpt, msg)
+}
+
+// anyFailed reports whether any DeliveryResult is outside the 2xx range,
+// used by SMTP mode to pick between a single "250 Queued" and a failure reply.
+func anyFailed(results []DeliveryResult) bool {
+	for _, res := range results {
+		if res.Code/100 != 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// enhancedStatusCode maps a basic SMTP reply code to a plausible RFC 3463
+// enhanced status code; it's advisory only, so a close approximation is fine.
+func enhancedStatusCode(code int) string {
+	switch {
+	case code/100 == 2:
+		return "2.1.5"
+	case code/100 == 4:
+		return "4.2.0"
+	default:
+		return "5.1.1"
+	}
+}
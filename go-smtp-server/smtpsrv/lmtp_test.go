@@ -0,0 +1,75 @@
+package smtpsrv
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+// lmtpBackend is a mock Backend that accepts every recipient except
+// "bad@y", to exercise LMTP's per-recipient status lines.
+type lmtpBackend struct{ rcpts []string }
+
+func (b *lmtpBackend) NewSession(conn *Conn) (Session, error) { return &lmtpSession{backend: b}, nil }
+
+type lmtpSession struct{ backend *lmtpBackend }
+
+func (s *lmtpSession) Mail(from string, opts *MailOptions) error { return nil }
+
+func (s *lmtpSession) Rcpt(to string, opts *RcptOptions) error {
+	s.backend.rcpts = append(s.backend.rcpts, to)
+	return nil
+}
+
+func (s *lmtpSession) Data(r io.Reader) ([]DeliveryResult, error) {
+	results := make([]DeliveryResult, len(s.backend.rcpts))
+	for i, rcpt := range s.backend.rcpts {
+		if rcpt == "bad@y" {
+			results[i] = DeliveryResult{Rcpt: rcpt, Code: 550, Message: "unknown user"}
+		} else {
+			results[i] = DeliveryResult{Rcpt: rcpt, Code: 250, Message: "delivered"}
+		}
+	}
+	return results, nil
+}
+
+func (s *lmtpSession) Reset() {}
+
+func (s *lmtpSession) Logout() error { return nil }
+
+func TestLMTPPerRecipientStatus(t *testing.T) {
+	backend := &lmtpBackend{}
+	stop, addr, err := New(WithBackend(backend)).StartLMTP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start lmtp: %v", err)
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer raw.Close()
+	r := bufio.NewReader(raw)
+	expect(t, readLine(r, t), "220")
+
+	// EHLO must be rejected on an LMTP listener.
+	_, _ = raw.Write([]byte("EHLO client\r\n"))
+	expect(t, readLine(r, t), "500")
+
+	_, _ = raw.Write([]byte("LHLO client\r\n"))
+	expect(t, readLine(r, t), "250")
+
+	_, _ = raw.Write([]byte("MAIL FROM:<a@b>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("RCPT TO:<good@x>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("RCPT TO:<bad@y>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("DATA\r\n"))
+	expect(t, readLine(r, t), "354")
+	_, _ = raw.Write([]byte("hi\r\n.\r\n"))
+	expect(t, readLine(r, t), "250 2.1.5 <good@x> delivered")
+	expect(t, readLine(r, t), "550 5.1.1 <bad@y> unknown user")
+}
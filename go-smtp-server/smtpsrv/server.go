@@ -0,0 +1,474 @@
+// server.go
+package smtpsrv
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// ─── Server ──────────────────────────────────────────────────────────────────
+
+// Server speaks SMTP (and, via StartLMTP, LMTP) against a Backend. Configure
+// it with New and the With* options rather than setting fields directly.
+type Server struct {
+	tlsCfg   *tls.Config
+	backend  Backend
+	auth     Authenticator
+	hostname string
+	maxSize  int
+}
+
+// Option configures a Server constructed by New.
+type Option func(*Server)
+
+// WithTLS sets the TLS configuration STARTTLS upgrades to. Without it, New
+// generates a self-signed certificate good for one year.
+func WithTLS(cfg *tls.Config) Option { return func(srv *Server) { srv.tlsCfg = cfg } }
+
+// WithBackend sets where accepted messages are delivered. Without it, a
+// Server accepts and silently discards every message.
+func WithBackend(b Backend) Option { return func(srv *Server) { srv.backend = b } }
+
+// WithAuthenticator enables SMTP AUTH (PLAIN, LOGIN, CRAM-MD5) once STARTTLS
+// has been negotiated. Without it, AUTH is not advertised and every sender is
+// accepted unauthenticated.
+func WithAuthenticator(auth Authenticator) Option { return func(srv *Server) { srv.auth = auth } }
+
+// WithHostname sets the name the server uses in its EHLO/LHLO greeting and
+// CRAM-MD5 challenges. Defaults to os.Hostname().
+func WithHostname(h string) Option { return func(srv *Server) { srv.hostname = h } }
+
+// defaultMaxSize caps the DATA phase when the caller doesn't call
+// WithMaxSize. handleDATA buffers the whole body in memory before handing
+// it to the Backend (Session.Data takes an io.Reader, but nothing streams
+// into it concurrently), so leaving the cap unbounded by default would let
+// any client force an unbounded allocation.
+const defaultMaxSize = 25 << 20 // 25 MiB
+
+// WithMaxSize caps the DATA stream in bytes; a DATA command that exceeds it
+// is rejected with "552 Message too large" before the Backend ever sees it.
+// Without this option a Server uses defaultMaxSize; pass 0 explicitly for
+// unlimited.
+func WithMaxSize(n int) Option { return func(srv *Server) { srv.maxSize = n } }
+
+// New returns a Server configured by opts.
+func New(opts ...Option) *Server {
+	srv := &Server{hostname: defaultHostname(), maxSize: defaultMaxSize}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	if srv.backend == nil {
+		srv.backend = discardBackend{}
+	}
+	return srv
+}
+
+// ─── STARTTLS certificate (self‑signed if none supplied) ──────────────────────
+
+// selfSignedCert generates a self-signed TLS certificate for STARTTLS support
+// when the caller hasn't supplied one via WithTLS.
+func selfSignedCert() tls.Certificate {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	templ := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtpmini"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+	}
+
+	der, _ := x509.CreateCertificate(rand.Reader, templ, templ, &key.PublicKey, key)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	cert, _ := tls.X509KeyPair(certPEM, keyPEM)
+	return cert
+}
+
+// ─── public bootstrap API ─────────────────────────────────────────────────────
+
+// Start launches the SMTP listener on addr. Returns a function to stop the
+// server, the actual address it's listening on, and any error that occurred
+// during startup.
+func (srv *Server) Start(addr string) (stop func() error, actualAddr string, err error) {
+	tlsCfg := srv.tlsCfg
+	if tlsCfg == nil {
+		cert := selfSignedCert()
+		tlsCfg = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	limiter := newAuthLimiter()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	go func() {
+		log.Printf("smtpmini listening on %s", ln.Addr())
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // Listener closed
+			}
+			go srv.handleConn(conn, tlsCfg, limiter, false)
+		}
+	}()
+
+	return ln.Close, ln.Addr().String(), nil
+}
+
+// ─── session state ────────────────────────────────────────────────────────────
+// session represents the protocol-level state of an SMTP conversation with a
+// client. The message itself (sender, recipients, body) is owned by the
+// Backend's Session; this struct only tracks what's needed to validate
+// command ordering.
+type session struct {
+	rcpts    int    // Number of recipients accepted for the in-flight message
+	step     string // Current protocol step: "", "mail", "rcpt", "data"
+	secure   bool   // Whether the connection is using TLS encryption
+	authUser string // Username from a successful AUTH; empty until authenticated
+}
+
+// reset clears the session state but preserves the secure and authUser flags,
+// which survive across messages on the same connection
+func (s *session) reset() { *s = session{secure: s.secure, authUser: s.authUser} }
+
+// ─── helpers ──────────────────────────────────────────────────────────────────
+
+// writeLine sends a line of text to the client with proper SMTP line endings (CRLF)
+func writeLine(w *bufio.Writer, line string) {
+	fmt.Fprintf(w, "%s\r\n", line)
+	_ = w.Flush()
+}
+
+// parseCmd splits an SMTP command line into the command and its argument
+// Commands are case-insensitive, so they're converted to uppercase
+func parseCmd(line string) (cmd, arg string) {
+	parts := strings.SplitN(line, " ", 2)
+	cmd = strings.ToUpper(parts[0])
+	if len(parts) == 2 {
+		arg = parts[1]
+	}
+	return
+}
+
+// stripAddr removes the angle brackets from an email address
+// e.g., "<user@example.com>" becomes "user@example.com"
+func stripAddr(v string) string {
+	v = strings.TrimSpace(v)
+	v = strings.TrimPrefix(v, "<")
+	v = strings.TrimSuffix(v, ">")
+	return v
+}
+
+// upgradeToTLS upgrades a connection to use TLS encryption
+// Returns the upgraded connection, new reader and writer, or an error if the handshake fails
+func upgradeToTLS(c net.Conn, tlsCfg *tls.Config, s *session) (net.Conn, *bufio.Scanner, *bufio.Writer, tls.ConnectionState, error) {
+	tlsConn := tls.Server(c, tlsCfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, nil, nil, tls.ConnectionState{}, err
+	}
+
+	r := bufio.NewScanner(tlsConn)
+	w := bufio.NewWriter(tlsConn)
+
+	s.secure = true
+	s.reset() // RFC requires discarding the prior SMTP state after STARTTLS
+
+	return tlsConn, r, w, tlsConn.ConnectionState(), nil
+}
+
+// errMessageTooLarge is returned by handleDATA when the stream exceeds
+// Server.maxSize.
+var errMessageTooLarge = fmt.Errorf("message exceeds maximum size")
+
+// handleDATA reads the message body until a line containing just "." and
+// returns it as an io.Reader. It does not stream: the whole body is
+// buffered here before the caller hands it to the Backend, so nothing below
+// maxSize ever reaches Session.Data incrementally. The io.Reader return type
+// matches Session.Data's signature for a future streaming implementation
+// (e.g. an io.Pipe fed concurrently with the scan loop); today it's always a
+// fully-populated *bytes.Buffer. maxSize caps the accumulated body in bytes;
+// zero means unlimited — callers going through New rather than constructing
+// a Server directly get defaultMaxSize unless they opt out via WithMaxSize.
+func handleDATA(r *bufio.Scanner, maxSize int) (io.Reader, error) {
+	var buf bytes.Buffer
+	tooLarge := false
+	for r.Scan() {
+		l := r.Text()
+		if l == "." {
+			if tooLarge {
+				return nil, errMessageTooLarge
+			}
+			return &buf, nil
+		}
+		if tooLarge {
+			// Already over the limit: keep draining to the terminator
+			// instead of returning early, so the scanner stays in sync with
+			// the client's still-incoming body lines and doesn't mistake
+			// them for the next SMTP command.
+			continue
+		}
+		if maxSize > 0 && buf.Len()+len(l)+2 > maxSize {
+			tooLarge = true
+			buf.Reset()
+			continue
+		}
+		buf.WriteString(l)
+		buf.WriteString("\r\n")
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// ─── connection handler ───────────────────────────────────────────────────────
+
+// handleConn processes an individual SMTP (or, with lmtp set, LMTP) client
+// connection. It implements the core protocol state machine.
+func (srv *Server) handleConn(c net.Conn, tlsCfg *tls.Config, limiter *authLimiter, lmtp bool) {
+	defer c.Close()
+
+	r := bufio.NewScanner(c)
+	w := bufio.NewWriter(c)
+	s := &session{}
+	conn := &Conn{netConn: c}
+
+	sess, err := srv.backend.NewSession(conn)
+	if err != nil {
+		log.Printf("backend refused new session: %v", err)
+		writeLine(w, "451 4.3.0 Temporary server error")
+		return
+	}
+	defer func() { _ = sess.Logout() }()
+
+	log.Printf("New connection from %s", c.RemoteAddr())
+	writeLine(w, fmt.Sprintf("220 %s ESMTP ready", srv.hostname))
+
+	for {
+		if !r.Scan() {
+			if err := r.Err(); err != nil {
+				log.Printf("conn error: %v", err)
+			}
+			log.Printf("Connection closed")
+			return
+		}
+		cmd, arg := parseCmd(r.Text())
+		log.Printf("Received command: %s %s", cmd, arg)
+
+		switch cmd {
+		case "EHLO", "HELO":
+			// LMTP speaks LHLO instead; reject the SMTP greetings outright
+			if lmtp {
+				log.Printf("%s rejected on LMTP listener", cmd)
+				writeLine(w, "500 Use LHLO on an LMTP connection")
+				continue
+			}
+			if cmd == "HELO" {
+				// HELO is the older, simpler version of EHLO
+				s.reset()
+				sess.Reset()
+				log.Printf("Sending HELO response")
+				writeLine(w, "250 Hello")
+				continue
+			}
+			// EHLO initiates the SMTP session and identifies the client
+			// It also advertises server capabilities (extensions)
+			if !s.secure {
+				log.Printf("Sending EHLO response (insecure)")
+				writeLine(w, "250-"+srv.hostname)
+				writeLine(w, "250-STARTTLS") // Advertise STARTTLS capability
+				writeLine(w, "250 HELP")
+			} else if srv.auth != nil {
+				// Only advertise AUTH once STARTTLS has been negotiated,
+				// so credentials are never sent in the clear.
+				log.Printf("Sending EHLO response (secure, AUTH advertised)")
+				writeLine(w, "250-"+srv.hostname)
+				writeLine(w, "250 AUTH PLAIN LOGIN CRAM-MD5")
+			} else {
+				log.Printf("Sending EHLO response (secure)")
+				writeLine(w, "250 "+srv.hostname)
+			}
+
+		case "LHLO":
+			// LHLO is LMTP's stand-in for EHLO (RFC 2033 §4). LMTP is for
+			// trusted local hops, so STARTTLS/AUTH are never advertised.
+			if !lmtp {
+				log.Printf("LHLO rejected on SMTP listener")
+				writeLine(w, "500 Use EHLO/HELO on an SMTP connection")
+				continue
+			}
+			s.reset()
+			sess.Reset()
+			log.Printf("Sending LHLO response")
+			writeLine(w, "250 "+srv.hostname)
+
+		case "STARTTLS":
+			if lmtp {
+				log.Printf("STARTTLS rejected on LMTP listener")
+				writeLine(w, "500 Command not supported")
+				continue
+			}
+			if s.secure {
+				log.Printf("Already in TLS mode")
+				writeLine(w, "503 Already under TLS")
+				continue
+			}
+			log.Printf("Starting TLS handshake")
+			writeLine(w, "220 Ready to start TLS")
+
+			var tlsState tls.ConnectionState
+			c, r, w, tlsState, err = upgradeToTLS(c, tlsCfg, s)
+			if err != nil {
+				log.Printf("TLS handshake failed: %v", err)
+				return
+			}
+			conn.netConn = c
+			conn.tlsState = &tlsState
+
+			log.Printf("TLS handshake successful")
+			continue // Wait for client to issue EHLO again on the secure connection
+
+		case "AUTH":
+			if !s.secure {
+				log.Printf("AUTH attempted on insecure connection")
+				writeLine(w, "538 5.7.11 Encryption required")
+				continue
+			}
+			if !limiter.allowed(limiterKey(c.RemoteAddr())) {
+				log.Printf("AUTH rate-limited for %s", c.RemoteAddr())
+				writeLine(w, "454 4.7.0 Too many authentication failures, try again later")
+				continue
+			}
+			if srv.auth == nil {
+				writeLine(w, "504 Authentication not supported")
+				continue
+			}
+			user, err := srv.handleAUTH(r, w, arg)
+			if err != nil {
+				log.Printf("AUTH failed: %v", err)
+				limiter.recordFailure(limiterKey(c.RemoteAddr()))
+				writeLine(w, "535 5.7.8 Authentication credentials invalid")
+				continue
+			}
+			s.authUser = user
+			log.Printf("AUTH succeeded for %s", user)
+			writeLine(w, "235 2.7.0 Authentication successful")
+
+		case "MAIL":
+			if srv.auth != nil && s.authUser == "" {
+				log.Printf("MAIL FROM without authentication")
+				writeLine(w, "530 5.7.0 Authentication required")
+				continue
+			}
+			from, opts, perr := parseMailFrom(arg)
+			if perr != nil {
+				log.Printf("Invalid MAIL syntax")
+				writeLine(w, "501 "+perr.Error())
+				continue
+			}
+			s.reset()
+			sess.Reset()
+			if err := sess.Mail(from, opts); err != nil {
+				log.Printf("backend rejected MAIL FROM %s: %v", from, err)
+				writeLine(w, "550 5.7.1 Mail from rejected")
+				continue
+			}
+			s.step = "mail"
+			log.Printf("MAIL FROM accepted: %s", from)
+			writeLine(w, "250 OK")
+
+		case "RCPT":
+			if s.step == "" {
+				log.Printf("RCPT without MAIL")
+				writeLine(w, "503 Need MAIL FROM first")
+				continue
+			}
+			to, opts, perr := parseRcptTo(arg)
+			if perr != nil {
+				log.Printf("Invalid RCPT syntax")
+				writeLine(w, "501 "+perr.Error())
+				continue
+			}
+			if err := sess.Rcpt(to, opts); err != nil {
+				log.Printf("backend rejected RCPT TO %s: %v", to, err)
+				writeLine(w, "550 5.1.1 Recipient rejected")
+				continue
+			}
+			s.rcpts++
+			s.step = "rcpt"
+			log.Printf("RCPT TO accepted: %s", to)
+			writeLine(w, "250 OK")
+
+		case "DATA":
+			if s.step != "rcpt" {
+				log.Printf("DATA without RCPT")
+				writeLine(w, "503 Need RCPT TO first")
+				continue
+			}
+			log.Printf("Starting DATA phase")
+			writeLine(w, "354 End with <CRLF>.<CRLF>")
+
+			body, err := handleDATA(r, srv.maxSize)
+			if err != nil {
+				if err == errMessageTooLarge {
+					log.Printf("message exceeded max size")
+					writeLine(w, "552 5.3.4 Message too large")
+				} else {
+					log.Printf("Error reading DATA: %v", err)
+					writeLine(w, "500 Error reading DATA")
+				}
+				s.reset()
+				sess.Reset()
+				continue
+			}
+
+			results, err := sess.Data(body)
+			if err != nil {
+				log.Printf("backend rejected DATA: %v", err)
+				writeLine(w, "550 5.7.1 Message rejected")
+				s.reset()
+				sess.Reset()
+				continue
+			}
+
+			if lmtp {
+				// RFC 2033: one status line per recipient, in RCPT order.
+				for _, res := range results {
+					writeLine(w, lmtpStatusLine(res))
+				}
+			} else if anyFailed(results) {
+				writeLine(w, "550 5.1.1 One or more recipients rejected")
+			} else {
+				writeLine(w, "250 Queued")
+			}
+			s.reset() // Reset session state for the next message
+			sess.Reset()
+
+		case "QUIT":
+			log.Printf("Client requested QUIT")
+			writeLine(w, "221 Bye")
+			return
+
+		default:
+			log.Printf("Unrecognized command: %s", cmd)
+			writeLine(w, "500 Unrecognised command")
+		}
+	}
+}
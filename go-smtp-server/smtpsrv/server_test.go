@@ -1,4 +1,4 @@
-package main
+package smtpsrv
 
 import (
 	"bufio"
@@ -22,7 +22,7 @@ func expect(t *testing.T, got, wantPrefix string) {
 }
 
 func TestStartTLSConversation(t *testing.T) {
-	stop, addr, err := Start("127.0.0.1:0")
+	stop, addr, err := New().Start("127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("start: %v", err)
 	}
@@ -43,9 +43,6 @@ func TestStartTLSConversation(t *testing.T) {
 		line := readLine(r, t)
 		if strings.HasPrefix(line, "250-") {
 			// expecting at least STARTTLS in one of the dash lines
-			if strings.Contains(line, "STARTTLS") {
-				// noted, keep looping
-			}
 			continue
 		}
 		// line starts with "250 " → final line
@@ -63,7 +60,7 @@ func TestStartTLSConversation(t *testing.T) {
 	rTLS := bufio.NewReader(tlsConn)
 
 	_, _ = tlsConn.Write([]byte("EHLO client\r\n"))
-	expect(t, readLine(rTLS, t), "250 smtpmini")
+	expect(t, readLine(rTLS, t), "250")
 
 	// rest of pipeline ------------------------------------------------------
 	_, _ = tlsConn.Write([]byte("MAIL FROM:<a@b>\r\n"))
@@ -77,3 +74,55 @@ func TestStartTLSConversation(t *testing.T) {
 	_, _ = tlsConn.Write([]byte("QUIT\r\n"))
 	expect(t, readLine(rTLS, t), "221")
 }
+
+// TestHandleDATAOversizedMessageStaysInSync exercises the WithMaxSize limit:
+// a message over the cap must be rejected with 552, and the connection must
+// stay in sync for the next command instead of treating the rest of the
+// client's DATA stream as unrecognized commands.
+func TestHandleDATAOversizedMessageStaysInSync(t *testing.T) {
+	backend := &recordingBackend{}
+	stop, addr, err := New(WithBackend(backend), WithMaxSize(16)).Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = stop() })
+
+	raw, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer raw.Close()
+	r := bufio.NewReader(raw)
+	expect(t, readLine(r, t), "220")
+
+	_, _ = raw.Write([]byte("EHLO client\r\n"))
+	for {
+		line := readLine(r, t)
+		if strings.HasPrefix(line, "250-") {
+			continue
+		}
+		break
+	}
+	_, _ = raw.Write([]byte("MAIL FROM:<a@b>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("RCPT TO:<c@d>\r\n"))
+	expect(t, readLine(r, t), "250")
+	_, _ = raw.Write([]byte("DATA\r\n"))
+	expect(t, readLine(r, t), "354")
+
+	// This body is well over the 16-byte cap; the terminating "." only
+	// arrives after several more lines of body.
+	_, _ = raw.Write([]byte("this line alone is already longer than the cap\r\nanother body line\r\n.\r\n"))
+	expect(t, readLine(r, t), "552")
+
+	// If handleDATA didn't drain to the "." terminator, the server would now
+	// read stray body bytes as the next command instead of this QUIT.
+	_, _ = raw.Write([]byte("QUIT\r\n"))
+	expect(t, readLine(r, t), "221")
+
+	for _, call := range backend.calls {
+		if strings.HasPrefix(call, "Data:") {
+			t.Fatalf("backend.Data should not have been called for an oversized message, calls: %v", backend.calls)
+		}
+	}
+}
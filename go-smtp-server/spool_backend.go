@@ -0,0 +1,72 @@
+// spool_backend.go
+package main
+
+import (
+	"io"
+	"log"
+
+	"smtpmini/queue"
+	"smtpmini/smtpsrv"
+)
+
+// ─── default disk-spool backend ────────────────────────────────────────────────
+
+// SpoolBackend is the original smtpmini behaviour (write each message to the
+// Queue as JSON for the retry scheduler to pick up) expressed as a
+// smtpsrv.Backend.
+type SpoolBackend struct {
+	Queue *queue.Queue
+}
+
+// NewSpoolBackend returns a Backend that spools every accepted message onto q.
+func NewSpoolBackend(q *queue.Queue) *SpoolBackend {
+	return &SpoolBackend{Queue: q}
+}
+
+func (b *SpoolBackend) NewSession(conn *smtpsrv.Conn) (smtpsrv.Session, error) {
+	return &spoolSession{backend: b}, nil
+}
+
+type spoolSession struct {
+	backend *SpoolBackend
+	from    string
+	rcpts   []string
+}
+
+func (s *spoolSession) Mail(from string, opts *smtpsrv.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *spoolSession) Rcpt(to string, opts *smtpsrv.RcptOptions) error {
+	s.rcpts = append(s.rcpts, to)
+	return nil
+}
+
+func (s *spoolSession) Data(r io.Reader) ([]smtpsrv.DeliveryResult, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &queue.Message{
+		From:     s.from,
+		Rcpts:    append([]string(nil), s.rcpts...),
+		Data:     string(body),
+		Envelope: queue.Envelope{From: s.from},
+	}
+	if err := s.backend.Queue.Enqueue(msg); err != nil {
+		log.Printf("[queue] enqueue error: %v", err)
+	}
+
+	// A single spool write covers every recipient, so they all succeed together.
+	results := make([]smtpsrv.DeliveryResult, len(s.rcpts))
+	for i, rcpt := range s.rcpts {
+		results[i] = smtpsrv.DeliveryResult{Rcpt: rcpt, Code: 250, Message: "queued"}
+	}
+	return results, nil
+}
+
+func (s *spoolSession) Reset() { s.from = ""; s.rcpts = nil }
+
+func (s *spoolSession) Logout() error { return nil }